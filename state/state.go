@@ -93,41 +93,169 @@ type Locker interface {
 // test hook to verify that LockWithContext has attempted a lock
 var postLockHook func()
 
-// Lock the state, using the provided context for timeout and cancellation.
-// This backs off slightly to an upper limit.
+// LockRetryPolicy decides how long to wait between attempts to acquire a
+// state lock, and whether locking should be retried at all. attempt is
+// 1-indexed and lastErr is the *LockError returned by the most recent
+// failed attempt. Returning ok == false stops retrying and causes
+// LockWithContextAndPolicy to return lastErr.
+//
+// Implementations can use lastErr.Category to give up early on errors
+// that retrying can't fix, such as LockErrorCategoryPermission, while
+// continuing to retry LockErrorCategoryConflict.
+type LockRetryPolicy interface {
+	NextDelay(attempt int, lastErr *LockError) (delay time.Duration, ok bool)
+}
+
+// LockAttemptStats reports how much work LockWithContextAndPolicy did to
+// acquire a lock, for callers that want to log or surface lock contention.
+type LockAttemptStats struct {
+	// Attempts is the number of calls made to Locker.Lock, including the
+	// final, successful one.
+	Attempts int
+
+	// TotalWait is the cumulative time spent waiting between attempts.
+	TotalWait time.Duration
+}
+
+// DecorrelatedJitterPolicy is the default LockRetryPolicy. It grows an
+// envelope of Base doubled per attempt, and picks each delay at random
+// between Base and three times that envelope, never more than Cap, so that
+// many callers contending for the same lock (for example, a fleet of CI
+// workers) don't retry in lockstep.
+type DecorrelatedJitterPolicy struct {
+	// Base is the minimum delay between attempts; the first retry waits
+	// between Base and three times Base. Defaults to 1 second.
+	Base time.Duration
+
+	// Cap is the maximum delay between retries. Defaults to 16 seconds.
+	Cap time.Duration
+
+	// MaxAttempts, if greater than zero, stops retrying once this many
+	// attempts have been made. Zero means retry until ctx is done.
+	MaxAttempts int
+}
+
+// NewDecorrelatedJitterPolicy returns a DecorrelatedJitterPolicy configured
+// with Terraform's historical defaults: a 1 second base delay backing off
+// to a 16 second cap, with no limit on the number of attempts.
+func NewDecorrelatedJitterPolicy() *DecorrelatedJitterPolicy {
+	return &DecorrelatedJitterPolicy{
+		Base: time.Second,
+		Cap:  16 * time.Second,
+	}
+}
+
+func (p *DecorrelatedJitterPolicy) NextDelay(attempt int, lastErr *LockError) (time.Duration, bool) {
+	if lastErr != nil && lastErr.Category == LockErrorCategoryPermission {
+		// Retrying isn't going to fix a permission error.
+		return 0, false
+	}
+	if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	base := p.Base
+	if base <= 0 {
+		base = time.Second
+	}
+	max := p.Cap
+	if max <= 0 {
+		max = 16 * time.Second
+	}
+
+	envelope := base << uint(attempt-1)
+	if envelope <= 0 || envelope > max {
+		envelope = max
+	}
+
+	upper := envelope * 3
+	if upper <= 0 || upper > max {
+		upper = max
+	}
+	if upper <= base {
+		return base, true
+	}
+
+	// Unlike NewLockInfo's one-off use of rngSource below, NextDelay is
+	// called on every retry iteration of every in-flight lock acquisition,
+	// so it needs a source that's actually safe for concurrent use. The
+	// top-level math/rand functions share a package-global source that
+	// serializes access internally, whereas rngSource is a plain *rand.Rand
+	// and would race under concurrent callers.
+	return base + time.Duration(rand.Int63n(int64(upper-base))), true
+}
+
+// LockErrorCategory classifies a LockError so that a LockRetryPolicy can
+// decide whether retrying is worthwhile.
+type LockErrorCategory int
+
+const (
+	// LockErrorCategoryUnknown is the default category for a LockError
+	// that hasn't been classified by its Backend.
+	LockErrorCategoryUnknown LockErrorCategory = iota
+
+	// LockErrorCategoryConflict indicates that the lock is currently held
+	// by someone else. This is transient: the lock may be released before
+	// the caller's context is done.
+	LockErrorCategoryConflict
+
+	// LockErrorCategoryPermission indicates that the caller isn't
+	// permitted to acquire the lock. Retrying won't help.
+	LockErrorCategoryPermission
+)
+
+// LockWithContext locks the state, using the provided context for timeout
+// and cancellation, using the default DecorrelatedJitterPolicy.
 func LockWithContext(ctx context.Context, s State, info *LockInfo) (string, error) {
-	delay := time.Second
-	maxDelay := 16 * time.Second
+	id, _, err := LockWithContextAndPolicy(ctx, s, info, NewDecorrelatedJitterPolicy())
+	return id, err
+}
+
+// LockWithContextAndPolicy locks the state, using the provided context for
+// timeout and cancellation, and policy to control the delay between
+// attempts and when to give up. It returns the acquired lock ID along with
+// LockAttemptStats describing how much retrying was needed.
+func LockWithContextAndPolicy(ctx context.Context, s State, info *LockInfo, policy LockRetryPolicy) (string, LockAttemptStats, error) {
+	if policy == nil {
+		policy = NewDecorrelatedJitterPolicy()
+	}
+
+	var stats LockAttemptStats
 	for {
+		stats.Attempts++
+
 		id, err := s.Lock(info)
 		if err == nil {
-			return id, nil
+			return id, stats, nil
 		}
 
 		le, ok := err.(*LockError)
 		if !ok {
 			// not a lock error, so we can't retry
-			return "", err
+			return "", stats, err
 		}
 
 		if le == nil || le.Info == nil || le.Info.ID == "" {
 			// If we dont' have a complete LockError, there's something wrong with the lock
-			return "", err
+			return "", stats, err
 		}
 
 		if postLockHook != nil {
 			postLockHook()
 		}
 
+		delay, ok := policy.NextDelay(stats.Attempts, le)
+		if !ok {
+			return "", stats, err
+		}
+
 		// there's an existing lock, wait and try again
 		select {
 		case <-ctx.Done():
 			// return the last lock error with the info
-			return "", err
+			return "", stats, err
 		case <-time.After(delay):
-			if delay < maxDelay {
-				delay *= 2
-			}
+			stats.TotalWait += delay
 		}
 	}
 }
@@ -167,6 +295,11 @@ type LockInfo = statemgr.LockInfo
 type LockError struct {
 	Info *LockInfo
 	Err  error
+
+	// Category classifies the failure so that a LockRetryPolicy can decide
+	// whether retrying is worthwhile. It defaults to
+	// LockErrorCategoryUnknown for backends that don't set it.
+	Category LockErrorCategory
 }
 
 func (e *LockError) Error() string {
@@ -0,0 +1,57 @@
+package state
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// countingLocker always reports a conflict, so LockWithContextAndPolicy
+// keeps retrying until the policy gives up.
+type countingLocker struct {
+	attempts int
+}
+
+func (l *countingLocker) Lock(info *LockInfo) (string, error) {
+	l.attempts++
+	return "", &LockError{
+		Info:     &LockInfo{ID: "conflict"},
+		Category: LockErrorCategoryConflict,
+	}
+}
+
+func (l *countingLocker) Unlock(id string) error { return nil }
+
+// fakeState only needs to satisfy State's interface; LockWithContextAndPolicy
+// never touches anything but Lock.
+type fakeState struct {
+	*countingLocker
+}
+
+func (fakeState) State() *terraform.State           { return nil }
+func (fakeState) WriteState(*terraform.State) error { return nil }
+func (fakeState) RefreshState() error               { return nil }
+func (fakeState) PersistState() error               { return nil }
+
+func TestDecorrelatedJitterPolicyMaxAttempts(t *testing.T) {
+	locker := &countingLocker{}
+	s := fakeState{locker}
+	policy := &DecorrelatedJitterPolicy{
+		Base:        time.Millisecond,
+		Cap:         time.Millisecond,
+		MaxAttempts: 2,
+	}
+
+	_, stats, err := LockWithContextAndPolicy(context.Background(), s, &LockInfo{}, policy)
+	if err == nil {
+		t.Fatal("expected an error, since the lock is never released")
+	}
+	if locker.attempts != 2 {
+		t.Fatalf("expected exactly 2 Lock calls for MaxAttempts: 2, got %d", locker.attempts)
+	}
+	if stats.Attempts != 2 {
+		t.Fatalf("expected stats.Attempts == 2, got %d", stats.Attempts)
+	}
+}
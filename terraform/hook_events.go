@@ -0,0 +1,387 @@
+package terraform
+
+import (
+	"sync"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+)
+
+// HookEventKind identifies which Hook callback a HookEvent was raised for.
+type HookEventKind int
+
+const (
+	HookEventUnknown HookEventKind = iota
+	HookEventPreApply
+	HookEventPostApply
+	HookEventPreDiff
+	HookEventPostDiff
+	HookEventPreProvisionInstance
+	HookEventPostProvisionInstance
+	HookEventPreProvisionInstanceStep
+	HookEventPostProvisionInstanceStep
+	HookEventProvisionOutput
+	HookEventPreRefresh
+	HookEventPostRefresh
+	HookEventPreImportState
+	HookEventPostImportState
+	HookEventPostStateUpdate
+
+	// HookEventStop has no direct Hook counterpart. It's published to ask
+	// any subscriber acting as a stop signal (see stopHook.SubscribeToBus)
+	// to halt, carrying the reason in HookEvent.StopReason.
+	HookEventStop
+)
+
+func (k HookEventKind) String() string {
+	switch k {
+	case HookEventPreApply:
+		return "PreApply"
+	case HookEventPostApply:
+		return "PostApply"
+	case HookEventPreDiff:
+		return "PreDiff"
+	case HookEventPostDiff:
+		return "PostDiff"
+	case HookEventPreProvisionInstance:
+		return "PreProvisionInstance"
+	case HookEventPostProvisionInstance:
+		return "PostProvisionInstance"
+	case HookEventPreProvisionInstanceStep:
+		return "PreProvisionInstanceStep"
+	case HookEventPostProvisionInstanceStep:
+		return "PostProvisionInstanceStep"
+	case HookEventProvisionOutput:
+		return "ProvisionOutput"
+	case HookEventPreRefresh:
+		return "PreRefresh"
+	case HookEventPostRefresh:
+		return "PostRefresh"
+	case HookEventPreImportState:
+		return "PreImportState"
+	case HookEventPostImportState:
+		return "PostImportState"
+	case HookEventPostStateUpdate:
+		return "PostStateUpdate"
+	case HookEventStop:
+		return "Stop"
+	default:
+		return "Unknown"
+	}
+}
+
+// HookEvent is a point-in-time notification mirroring a single Hook
+// callback invocation. Only the fields relevant to Kind are populated;
+// the rest are left at their zero value.
+//
+// HookEvent exists so that tooling that wants to observe the whole
+// lifecycle of a Terraform run - a progress UI, a tracer, a JSON-streaming
+// output mode - can subscribe once via RegisterHookSubscriber instead of
+// implementing the full Hook interface.
+type HookEvent struct {
+	Kind HookEventKind
+
+	Addr addrs.ResourceInstance
+	Gen  states.Generation
+
+	PriorState    cty.Value
+	ProposedState cty.Value
+	NewState      cty.Value
+	Err           error
+
+	ProvisionerType     string
+	ProvisionOutputLine string
+
+	ImportID string
+	Imported []*states.ImportedObject
+
+	State *states.State
+
+	// StopReason is populated for HookEventStop.
+	StopReason StopReason
+}
+
+// stopDeliveryTimeout bounds how long publishStop waits for a single stop
+// subscriber to accept an event before moving on to the rest. It's long
+// enough that a subscriber doing nothing but flipping an atomic (as
+// stopHook.SubscribeToBus does) will never plausibly hit it, but short
+// enough that a subscriber that's stopped consuming without unsubscribing
+// doesn't hang up delivery to everyone else.
+const stopDeliveryTimeout = 5 * time.Second
+
+// hookEventBus fans HookEvents out to registered subscribers.
+//
+// Ordinary lifecycle events (PreApply, PostApply, and so on) are sent
+// non-blocking: a subscriber that isn't keeping up with its channel misses
+// events rather than stalling the run that's publishing them. HookEventStop
+// is different - it's the one event a subscriber like stopHook must never
+// miss - so it's delivered over a separate, bounded-blocking path instead
+// of sharing the lossy general subscriber list. See publish vs publishStop.
+type hookEventBus struct {
+	mu              sync.Mutex
+	nextID          int
+	subscribers     map[int]chan<- HookEvent
+	nextStopID      int
+	stopSubscribers map[int]chan<- HookEvent
+}
+
+func newHookEventBus() *hookEventBus {
+	return &hookEventBus{
+		subscribers:     make(map[int]chan<- HookEvent),
+		stopSubscribers: make(map[int]chan<- HookEvent),
+	}
+}
+
+func (b *hookEventBus) subscribe(ch chan<- HookEvent) (unsubscribe func()) {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = ch
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		delete(b.subscribers, id)
+		b.mu.Unlock()
+	}
+}
+
+func (b *hookEventBus) subscribeStop(ch chan<- HookEvent) (unsubscribe func()) {
+	b.mu.Lock()
+	id := b.nextStopID
+	b.nextStopID++
+	b.stopSubscribers[id] = ch
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		delete(b.stopSubscribers, id)
+		b.mu.Unlock()
+	}
+}
+
+func (b *hookEventBus) publish(event HookEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// publishStop delivers event to every stop subscriber, blocking (up to
+// stopDeliveryTimeout per subscriber) rather than dropping the event if a
+// subscriber's channel is momentarily full. Like publish, it holds b.mu
+// for the whole call, so that a subscriber's unsubscribe func - which also
+// takes b.mu - can never return while a send to that subscriber's channel
+// is still in flight; that's what lets a subscriber safely close its
+// channel right after unsubscribing (see stopHook.SubscribeToBus).
+func (b *hookEventBus) publishStop(event HookEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, ch := range b.stopSubscribers {
+		select {
+		case ch <- event:
+		case <-time.After(stopDeliveryTimeout):
+			// The subscriber isn't draining; it has presumably stopped
+			// listening without unsubscribing. Move on rather than block
+			// delivery to the rest indefinitely.
+		}
+	}
+}
+
+// defaultHookEvents is the process-wide bus that RegisterHookSubscriber
+// and PublishHookEvent operate on.
+var defaultHookEvents = newHookEventBus()
+
+// RegisterHookSubscriber registers ch to receive a HookEvent for every
+// Hook callback of a run whose ContextOpts.Hooks includes a BusHook (runs
+// without one publish no lifecycle events), plus any HookEventStop
+// events published via PublishStopEvent. The caller must keep draining ch;
+// slow subscribers miss events rather than blocking the run. Call the
+// returned unsubscribe func to stop receiving events and release ch.
+//
+// A subscriber that must not miss a stop request (for example, one that
+// turns HookEventStop into an actual Stop) should use
+// RegisterStopSubscriber instead.
+func RegisterHookSubscriber(ch chan<- HookEvent) (unsubscribe func()) {
+	return defaultHookEvents.subscribe(ch)
+}
+
+// RegisterStopSubscriber registers ch to receive every HookEventStop
+// published via PublishStopEvent. Unlike RegisterHookSubscriber, delivery
+// here is never silently dropped: PublishStopEvent blocks, up to a bounded
+// timeout, until ch can accept the send, since a stop request is the one
+// event that must not be lost behind a backlog of ordinary lifecycle
+// events on a general subscriber's channel. Callers must still keep
+// draining ch promptly. Call the returned unsubscribe func to stop
+// receiving events and release ch.
+func RegisterStopSubscriber(ch chan<- HookEvent) (unsubscribe func()) {
+	return defaultHookEvents.subscribeStop(ch)
+}
+
+// PublishHookEvent broadcasts event to every subscriber registered via
+// RegisterHookSubscriber.
+func PublishHookEvent(event HookEvent) {
+	defaultHookEvents.publish(event)
+}
+
+// PublishStopEvent broadcasts a HookEventStop with the given reason to
+// both RegisterHookSubscriber subscribers (best-effort, like any other
+// event) and RegisterStopSubscriber subscribers (reliably, per
+// RegisterStopSubscriber's delivery guarantee), for example to a stopHook
+// that has opted in via SubscribeToBus.
+func PublishStopEvent(reason StopReason) {
+	event := HookEvent{Kind: HookEventStop, StopReason: reason}
+	defaultHookEvents.publish(event)
+	defaultHookEvents.publishStop(event)
+}
+
+// BusHook is a Hook implementation that publishes a HookEvent to the
+// default event bus for every callback it receives. Add one to
+// ContextOpts.Hooks to make a run's whole lifecycle observable via
+// RegisterHookSubscriber, without writing a bespoke Hook implementation
+// that calls PublishHookEvent by hand.
+type BusHook struct{}
+
+var _ Hook = BusHook{}
+
+func (BusHook) PreApply(addr addrs.ResourceInstance, gen states.Generation, priorState, plannedNewState cty.Value) (HookAction, error) {
+	PublishHookEvent(HookEvent{
+		Kind:          HookEventPreApply,
+		Addr:          addr,
+		Gen:           gen,
+		PriorState:    priorState,
+		ProposedState: plannedNewState,
+	})
+	return HookActionContinue, nil
+}
+
+func (BusHook) PostApply(addr addrs.ResourceInstance, gen states.Generation, newState cty.Value, err error) (HookAction, error) {
+	PublishHookEvent(HookEvent{
+		Kind:     HookEventPostApply,
+		Addr:     addr,
+		Gen:      gen,
+		NewState: newState,
+		Err:      err,
+	})
+	return HookActionContinue, nil
+}
+
+func (BusHook) PreDiff(addr addrs.ResourceInstance, priorState, proposedNewState cty.Value) (HookAction, error) {
+	PublishHookEvent(HookEvent{
+		Kind:          HookEventPreDiff,
+		Addr:          addr,
+		PriorState:    priorState,
+		ProposedState: proposedNewState,
+	})
+	return HookActionContinue, nil
+}
+
+func (BusHook) PostDiff(addr addrs.ResourceInstance, priorState, plannedNewState cty.Value) (HookAction, error) {
+	PublishHookEvent(HookEvent{
+		Kind:       HookEventPostDiff,
+		Addr:       addr,
+		PriorState: priorState,
+		NewState:   plannedNewState,
+	})
+	return HookActionContinue, nil
+}
+
+func (BusHook) PreProvisionInstance(addr addrs.ResourceInstance, state cty.Value) (HookAction, error) {
+	PublishHookEvent(HookEvent{
+		Kind:       HookEventPreProvisionInstance,
+		Addr:       addr,
+		PriorState: state,
+	})
+	return HookActionContinue, nil
+}
+
+func (BusHook) PostProvisionInstance(addr addrs.ResourceInstance, state cty.Value) (HookAction, error) {
+	PublishHookEvent(HookEvent{
+		Kind:     HookEventPostProvisionInstance,
+		Addr:     addr,
+		NewState: state,
+	})
+	return HookActionContinue, nil
+}
+
+func (BusHook) PreProvisionInstanceStep(addr addrs.ResourceInstance, typeName string) (HookAction, error) {
+	PublishHookEvent(HookEvent{
+		Kind:            HookEventPreProvisionInstanceStep,
+		Addr:            addr,
+		ProvisionerType: typeName,
+	})
+	return HookActionContinue, nil
+}
+
+func (BusHook) PostProvisionInstanceStep(addr addrs.ResourceInstance, typeName string, err error) (HookAction, error) {
+	PublishHookEvent(HookEvent{
+		Kind:            HookEventPostProvisionInstanceStep,
+		Addr:            addr,
+		ProvisionerType: typeName,
+		Err:             err,
+	})
+	return HookActionContinue, nil
+}
+
+func (BusHook) ProvisionOutput(addr addrs.ResourceInstance, typeName string, line string) {
+	PublishHookEvent(HookEvent{
+		Kind:                HookEventProvisionOutput,
+		Addr:                addr,
+		ProvisionerType:     typeName,
+		ProvisionOutputLine: line,
+	})
+}
+
+func (BusHook) PreRefresh(addr addrs.ResourceInstance, priorState cty.Value) (HookAction, error) {
+	PublishHookEvent(HookEvent{
+		Kind:       HookEventPreRefresh,
+		Addr:       addr,
+		PriorState: priorState,
+	})
+	return HookActionContinue, nil
+}
+
+func (BusHook) PostRefresh(addr addrs.ResourceInstance, priorState cty.Value, newState cty.Value) (HookAction, error) {
+	PublishHookEvent(HookEvent{
+		Kind:       HookEventPostRefresh,
+		Addr:       addr,
+		PriorState: priorState,
+		NewState:   newState,
+	})
+	return HookActionContinue, nil
+}
+
+func (BusHook) PreImportState(addr addrs.ResourceInstance, importID string) (HookAction, error) {
+	PublishHookEvent(HookEvent{
+		Kind:     HookEventPreImportState,
+		Addr:     addr,
+		ImportID: importID,
+	})
+	return HookActionContinue, nil
+}
+
+func (BusHook) PostImportState(addr addrs.ResourceInstance, imported []*states.ImportedObject) (HookAction, error) {
+	PublishHookEvent(HookEvent{
+		Kind:     HookEventPostImportState,
+		Addr:     addr,
+		Imported: imported,
+	})
+	return HookActionContinue, nil
+}
+
+func (BusHook) PostStateUpdate(new *states.State) (HookAction, error) {
+	PublishHookEvent(HookEvent{
+		Kind:  HookEventPostStateUpdate,
+		State: new,
+	})
+	return HookActionContinue, nil
+}
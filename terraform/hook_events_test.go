@@ -0,0 +1,66 @@
+package terraform
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/terraform/addrs"
+)
+
+func TestBusHookPublishesHookEvent(t *testing.T) {
+	ch := make(chan HookEvent, 1)
+	unsubscribe := RegisterHookSubscriber(ch)
+	defer unsubscribe()
+
+	var addr addrs.ResourceInstance
+	h := BusHook{}
+	if _, err := h.PreApply(addr, nil, cty.NilVal, cty.NilVal); err != nil {
+		t.Fatalf("PreApply returned an error: %s", err)
+	}
+
+	select {
+	case event := <-ch:
+		if event.Kind != HookEventPreApply {
+			t.Fatalf("expected HookEventPreApply, got %s", event.Kind)
+		}
+	default:
+		t.Fatal("expected BusHook.PreApply to publish a HookEvent to subscribers")
+	}
+}
+
+func TestRegisterStopSubscriberNotDropped(t *testing.T) {
+	ch := make(chan HookEvent, 1)
+	unsubscribe := RegisterStopSubscriber(ch)
+	defer unsubscribe()
+
+	// Fill the channel's buffer, so an immediate non-blocking send (as
+	// RegisterHookSubscriber subscribers get) would be dropped here.
+	ch <- HookEvent{Kind: HookEventStop, StopReason: StopReasonUserRequested}
+
+	done := make(chan struct{})
+	go func() {
+		PublishStopEvent(StopReasonUpstreamFailure)
+		close(done)
+	}()
+
+	// Drain the stale event so PublishStopEvent's blocking send can
+	// proceed.
+	<-ch
+
+	select {
+	case event := <-ch:
+		if event.StopReason != StopReasonUpstreamFailure {
+			t.Fatalf("expected StopReasonUpstreamFailure, got %s", event.StopReason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("PublishStopEvent dropped its event instead of blocking for the subscriber to drain")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("PublishStopEvent did not return once its event was delivered")
+	}
+}
@@ -1,6 +1,8 @@
 package terraform
 
 import (
+	"context"
+	"sync"
 	"sync/atomic"
 
 	"github.com/zclconf/go-cty/cty"
@@ -9,10 +11,76 @@ import (
 	"github.com/hashicorp/terraform/states"
 )
 
+// StopReason describes why a stopHook halted a run, so that callers can
+// surface something more useful than a generic "operation cancelled"
+// message.
+type StopReason uint32
+
+const (
+	// StopReasonNone indicates that no stop has been requested.
+	StopReasonNone StopReason = iota
+
+	// StopReasonUserRequested indicates that a stop was requested directly,
+	// for example via an interrupt from the CLI.
+	StopReasonUserRequested
+
+	// StopReasonDeadlineExceeded indicates that the context passed to
+	// StopGracefully expired before the graceful stop completed.
+	StopReasonDeadlineExceeded
+
+	// StopReasonUpstreamFailure indicates that the context passed to
+	// StopGracefully was cancelled because of a failure elsewhere in the
+	// run, rather than because its deadline expired.
+	StopReasonUpstreamFailure
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopReasonUserRequested:
+		return "user requested stop"
+	case StopReasonDeadlineExceeded:
+		return "deadline exceeded"
+	case StopReasonUpstreamFailure:
+		return "upstream failure"
+	default:
+		return "none"
+	}
+}
+
 // stopHook is a private Hook implementation that Terraform uses to
 // signal when to stop or cancel actions.
+//
+// A plain Stop halts immediately: every subsequent hook callback, whether
+// it guards the start of new work or reports the completion of work
+// already in flight, returns HookActionHalt. StopGracefully is softer: new
+// PreApply/PreDiff-style work is refused right away, but PostApply/
+// PostRefresh-style callbacks reporting work that was already underway are
+// still allowed to finish so in-flight actions aren't left half-applied.
 type stopHook struct {
-	stop uint32
+	graceful uint32
+
+	// genState packs the generation counter (upper 32 bits), the stop
+	// flag (bit 0), and the StopReason (bits 1-2) into a single word, so
+	// that StopGracefully's watcher goroutine can verify its generation
+	// is still current and commit a stop (flag and reason together) as
+	// one atomic compare-and-swap, rather than a load followed by
+	// separate stores that a racing Reset could interleave with. Reset
+	// increments the generation (and clears the stop flag and reason) so
+	// a watcher left over from a previous run can no longer affect a run
+	// h has since been reused for. See stopIfGeneration.
+	genState uint64
+}
+
+func packGenState(generation uint32, stopped bool, reason StopReason) uint64 {
+	var stop uint64
+	if stopped {
+		stop = 1
+	}
+	return uint64(generation)<<32 | uint64(reason)<<1 | stop
+}
+
+func unpackGenState(genState uint64) (generation uint32, stopped bool, reason StopReason) {
+	return uint32(genState >> 32), genState&1 == 1, StopReason(genState>>1) & 0x3
 }
 
 var _ Hook = (*stopHook)(nil)
@@ -22,7 +90,7 @@ func (h *stopHook) PreApply(addr addrs.ResourceInstance, gen states.Generation,
 }
 
 func (h *stopHook) PostApply(addr addrs.ResourceInstance, gen states.Generation, newState cty.Value, err error) (HookAction, error) {
-	return h.hook()
+	return h.completionHook()
 }
 
 func (h *stopHook) PreDiff(addr addrs.ResourceInstance, priorState, proposedNewState cty.Value) (HookAction, error) {
@@ -30,7 +98,7 @@ func (h *stopHook) PreDiff(addr addrs.ResourceInstance, priorState, proposedNewS
 }
 
 func (h *stopHook) PostDiff(addr addrs.ResourceInstance, priorState, plannedNewState cty.Value) (HookAction, error) {
-	return h.hook()
+	return h.completionHook()
 }
 
 func (h *stopHook) PreProvisionInstance(addr addrs.ResourceInstance, state cty.Value) (HookAction, error) {
@@ -38,7 +106,7 @@ func (h *stopHook) PreProvisionInstance(addr addrs.ResourceInstance, state cty.V
 }
 
 func (h *stopHook) PostProvisionInstance(addr addrs.ResourceInstance, state cty.Value) (HookAction, error) {
-	return h.hook()
+	return h.completionHook()
 }
 
 func (h *stopHook) PreProvisionInstanceStep(addr addrs.ResourceInstance, typeName string) (HookAction, error) {
@@ -46,7 +114,7 @@ func (h *stopHook) PreProvisionInstanceStep(addr addrs.ResourceInstance, typeNam
 }
 
 func (h *stopHook) PostProvisionInstanceStep(addr addrs.ResourceInstance, typeName string, err error) (HookAction, error) {
-	return h.hook()
+	return h.completionHook()
 }
 
 func (h *stopHook) ProvisionOutput(addr addrs.ResourceInstance, typeName string, line string) {
@@ -57,7 +125,7 @@ func (h *stopHook) PreRefresh(addr addrs.ResourceInstance, priorState cty.Value)
 }
 
 func (h *stopHook) PostRefresh(addr addrs.ResourceInstance, priorState cty.Value, newState cty.Value) (HookAction, error) {
-	return h.hook()
+	return h.completionHook()
 }
 
 func (h *stopHook) PreImportState(addr addrs.ResourceInstance, importID string) (HookAction, error) {
@@ -65,14 +133,28 @@ func (h *stopHook) PreImportState(addr addrs.ResourceInstance, importID string)
 }
 
 func (h *stopHook) PostImportState(addr addrs.ResourceInstance, imported []*states.ImportedObject) (HookAction, error) {
-	return h.hook()
+	return h.completionHook()
 }
 
 func (h *stopHook) PostStateUpdate(new *states.State) (HookAction, error) {
-	return h.hook()
+	return h.completionHook()
 }
 
+// hook guards the start of new work. It halts on either a hard Stop or a
+// graceful StopGracefully, since graceful stops must still refuse to begin
+// anything new.
 func (h *stopHook) hook() (HookAction, error) {
+	if h.Stopped() || h.stoppingGracefully() {
+		return HookActionHalt, nil
+	}
+
+	return HookActionContinue, nil
+}
+
+// completionHook guards callbacks that report the completion of work that
+// was already in flight. It only halts on a hard Stop, so that a graceful
+// stop lets those in-flight actions finish.
+func (h *stopHook) completionHook() (HookAction, error) {
 	if h.Stopped() {
 		return HookActionHalt, nil
 	}
@@ -82,13 +164,177 @@ func (h *stopHook) hook() (HookAction, error) {
 
 // reset should be called within the lock context
 func (h *stopHook) Reset() {
-	atomic.StoreUint32(&h.stop, 0)
+	for {
+		old := atomic.LoadUint64(&h.genState)
+		generation, _, _ := unpackGenState(old)
+		next := packGenState(generation+1, false, StopReasonNone)
+		if atomic.CompareAndSwapUint64(&h.genState, old, next) {
+			break
+		}
+	}
+	atomic.StoreUint32(&h.graceful, 0)
 }
 
+// Stop halts immediately: no further hook callback will return
+// HookActionContinue, including those reporting work already in flight.
 func (h *stopHook) Stop() {
-	atomic.StoreUint32(&h.stop, 1)
+	h.stopWithReason(StopReasonUserRequested)
 }
 
 func (h *stopHook) Stopped() bool {
-	return atomic.LoadUint32(&h.stop) == 1
+	_, stopped, _ := unpackGenState(atomic.LoadUint64(&h.genState))
+	return stopped
+}
+
+// currentGeneration returns h's current generation, for a StopGracefully
+// watcher to capture and later compare against via stopIfGeneration.
+func (h *stopHook) currentGeneration() uint32 {
+	generation, _, _ := unpackGenState(atomic.LoadUint64(&h.genState))
+	return generation
+}
+
+// StopGracefully refuses to start any new work immediately, but allows
+// work already in flight to report its completion. If ctx is cancelled
+// before the graceful stop otherwise completes, it is escalated to a hard
+// Stop, with the reason reflecting whether the context's deadline expired
+// or it was cancelled for some other reason (for example, a failure
+// elsewhere in the run).
+//
+// StopGracefully starts a goroutine to watch ctx, so callers must arrange
+// for ctx to eventually be done. If the graceful stop finishes cleanly on
+// its own - rather than because ctx ran out - call the returned done func
+// so that watcher can exit without mistaking the caller's own subsequent
+// cancellation of ctx for an upstream failure. done is safe to call more
+// than once and safe to call after ctx is already done.
+func (h *stopHook) StopGracefully(ctx context.Context) (done func()) {
+	atomic.StoreUint32(&h.graceful, 1)
+	h.setReason(StopReasonUserRequested)
+
+	generation := h.currentGeneration()
+	finished := make(chan struct{})
+	var finishedOnce sync.Once
+
+	go func() {
+		select {
+		case <-finished:
+			return
+		case <-ctx.Done():
+		}
+
+		select {
+		case <-finished:
+			// The caller already reported the graceful stop as finished
+			// cleanly; it raced with ctx being cancelled, so don't
+			// escalate.
+			return
+		default:
+		}
+
+		reason := StopReasonUpstreamFailure
+		if ctx.Err() == context.DeadlineExceeded {
+			reason = StopReasonDeadlineExceeded
+		}
+
+		// stopIfGeneration verifies the generation and commits the stop
+		// flag and reason together as a single atomic compare-and-swap,
+		// so there's no window between checking that h hasn't been Reset
+		// and actually stopping it in which a concurrent Reset could
+		// slip through.
+		h.stopIfGeneration(generation, reason)
+	}()
+
+	return func() {
+		finishedOnce.Do(func() { close(finished) })
+	}
+}
+
+func (h *stopHook) stoppingGracefully() bool {
+	return atomic.LoadUint32(&h.graceful) == 1
+}
+
+// stopWithReason sets the stop flag and reason together, regardless of
+// generation. It's used by Stop and by SubscribeToBus's HookEventStop
+// handler, which both mean to stop whatever run h currently represents.
+func (h *stopHook) stopWithReason(reason StopReason) {
+	for {
+		old := atomic.LoadUint64(&h.genState)
+		generation, _, _ := unpackGenState(old)
+		next := packGenState(generation, true, reason)
+		if atomic.CompareAndSwapUint64(&h.genState, old, next) {
+			return
+		}
+	}
+}
+
+// stopIfGeneration sets the stop flag and reason, but only if h is still
+// on the given generation and not already stopped. It reports whether it
+// did so. Unlike stopWithReason, the generation check and the write are a
+// single compare-and-swap, so a watcher goroutine that captured generation
+// before calling this can't race a concurrent Reset: either it observes
+// the pre-Reset generation and stops that run, or it observes the new
+// generation (or finds h already stopped) and does nothing.
+func (h *stopHook) stopIfGeneration(generation uint32, reason StopReason) bool {
+	for {
+		old := atomic.LoadUint64(&h.genState)
+		curGeneration, stopped, _ := unpackGenState(old)
+		if curGeneration != generation || stopped {
+			return false
+		}
+		next := packGenState(curGeneration, true, reason)
+		if atomic.CompareAndSwapUint64(&h.genState, old, next) {
+			return true
+		}
+	}
+}
+
+// setReason updates the reason without changing the stop flag or
+// generation. It's used to record that a graceful stop has begun, ahead
+// of it potentially being escalated to a hard stop later.
+func (h *stopHook) setReason(reason StopReason) {
+	for {
+		old := atomic.LoadUint64(&h.genState)
+		generation, stopped, _ := unpackGenState(old)
+		next := packGenState(generation, stopped, reason)
+		if atomic.CompareAndSwapUint64(&h.genState, old, next) {
+			return
+		}
+	}
+}
+
+// StopReason reports why this stopHook stopped or is stopping, for callers
+// that want to surface something more specific than a generic "operation
+// cancelled" message. It returns StopReasonNone if no stop has been
+// requested.
+func (h *stopHook) StopReason() StopReason {
+	_, _, reason := unpackGenState(atomic.LoadUint64(&h.genState))
+	return reason
+}
+
+// SubscribeToBus registers h as a hook event bus subscriber, so that a
+// HookEventStop published via PublishStopEvent (from anywhere: a signal
+// handler, another goroutine tracking an upstream failure) is turned into
+// a Stop on h. It uses RegisterStopSubscriber rather than
+// RegisterHookSubscriber so that a burst of ordinary lifecycle events
+// (BusHook firing for many resources at once, say) can never cause the
+// stop itself to be dropped. Call the returned unsubscribe func to stop
+// listening.
+func (h *stopHook) SubscribeToBus() (unsubscribe func()) {
+	ch := make(chan HookEvent, 1)
+	unsub := RegisterStopSubscriber(ch)
+
+	go func() {
+		for event := range ch {
+			if event.Kind == HookEventStop {
+				h.stopWithReason(event.StopReason)
+			}
+		}
+	}()
+
+	return func() {
+		// unsub happens under the bus's lock, so once it returns no more
+		// sends to ch are possible and it's safe to close, letting the
+		// goroutine above exit its range loop.
+		unsub()
+		close(ch)
+	}
 }
@@ -0,0 +1,104 @@
+package terraform
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStopHookStopGracefullyIgnoresStaleGeneration(t *testing.T) {
+	h := &stopHook{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.StopGracefully(ctx)
+
+	// Simulate the run that called StopGracefully finishing and h being
+	// handed to a brand new run before ctx is ever cancelled.
+	h.Reset()
+
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	if h.Stopped() {
+		t.Fatal("a StopGracefully watcher from a previous generation wrongly stopped the current run")
+	}
+}
+
+func TestStopHookStopGracefullyDoneSuppressesEscalation(t *testing.T) {
+	h := &stopHook{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := h.StopGracefully(ctx)
+	done()
+	cancel()
+
+	time.Sleep(50 * time.Millisecond)
+
+	if h.Stopped() {
+		t.Fatal("calling done before ctx is cancelled should suppress escalation")
+	}
+}
+
+func TestStopHookStopGracefullyEscalatesOnDeadlineExceeded(t *testing.T) {
+	h := &stopHook{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	h.StopGracefully(ctx)
+
+	time.Sleep(50 * time.Millisecond)
+
+	if !h.Stopped() {
+		t.Fatal("expected StopGracefully to escalate to a hard stop once ctx's deadline passed")
+	}
+	if got := h.StopReason(); got != StopReasonDeadlineExceeded {
+		t.Fatalf("expected StopReasonDeadlineExceeded, got %s", got)
+	}
+}
+
+// TestStopHookStopGracefullyGenerationRaceIsAtomic stresses the narrow
+// window between StopGracefully's watcher observing a generation and it
+// acting on that observation: stopIfGeneration must check the generation
+// and set the stop flag as a single compare-and-swap, or a Reset landing
+// in between would let a stale watcher stop the wrong run.
+func TestStopHookStopGracefullyGenerationRaceIsAtomic(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		h := &stopHook{}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel() // already done, so the watcher goroutine races Reset below
+
+		h.StopGracefully(ctx)
+		h.Reset()
+
+		time.Sleep(time.Millisecond)
+
+		if h.Stopped() {
+			t.Fatal("StopGracefully's watcher stopped a generation it should have excluded itself from")
+		}
+	}
+}
+
+func TestStopHookSubscribeToBusDeliversStopUnderBacklog(t *testing.T) {
+	h := &stopHook{}
+	unsubscribe := h.SubscribeToBus()
+	defer unsubscribe()
+
+	// Simulate heavy ordinary lifecycle event traffic (as BusHook would
+	// produce across many resources) competing for the bus; it shouldn't
+	// matter, since stop delivery to h uses RegisterStopSubscriber's
+	// non-lossy path rather than the general, lossy one.
+	for i := 0; i < 100; i++ {
+		PublishHookEvent(HookEvent{Kind: HookEventPreApply})
+	}
+
+	PublishStopEvent(StopReasonUserRequested)
+
+	deadline := time.Now().Add(time.Second)
+	for !h.Stopped() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected PublishStopEvent to reach stopHook via SubscribeToBus")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}